@@ -0,0 +1,90 @@
+package graphite
+
+import (
+	"bytes"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// ParseLines scans a "\n"-delimited buffer of graphite lines in a single
+// pass and appends the decoded points to out, returning the resulting
+// slice. Unlike repeated calls to Parse, it splits each line by
+// index-slicing directly into buf rather than via strings.Fields, and
+// runs the metric name through Template.ApplyBytes with a single reused
+// ParseResult instead of Apply's map/strings.Join-based path, so a TCP
+// or UDP graphite listener processing a connection's worth of metrics
+// at once can avoid the bulk of Parse's per-line allocations.
+func (p *Parser) ParseLines(buf []byte, out []models.Point) ([]models.Point, error) {
+	var scratch [3][]byte
+	var dst ParseResult
+
+	for start := 0; start < len(buf); {
+		var line []byte
+		if i := bytes.IndexByte(buf[start:], '\n'); i >= 0 {
+			line = buf[start : start+i]
+			start += i + 1
+		} else {
+			line = buf[start:]
+			start = len(buf)
+		}
+		line = bytes.TrimRight(line, "\r")
+		if len(line) == 0 {
+			continue
+		}
+
+		fields := splitFieldsBytes(line, scratch[:0])
+		point, err := p.parseFieldsBytes(fields, &dst)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, point)
+	}
+	return out, nil
+}
+
+// splitFieldsBytes appends the whitespace-delimited tokens of line to
+// dst as sub-slices of line, with no copying. Unlike
+// strings.Fields(string(line)), it splits only on the ASCII whitespace
+// bytes accepted by isGraphiteSpace (not on '\n' or non-ASCII spaces),
+// never materializes the line or its tokens as strings, and lets the
+// caller reuse dst's backing array across calls.
+func splitFieldsBytes(line []byte, dst [][]byte) [][]byte {
+	i := 0
+	for i < len(line) {
+		for i < len(line) && isGraphiteSpace(line[i]) {
+			i++
+		}
+		if i >= len(line) {
+			break
+		}
+		j := i
+		for j < len(line) && !isGraphiteSpace(line[j]) {
+			j++
+		}
+		dst = append(dst, line[i:j])
+		i = j
+	}
+	return dst
+}
+
+func isGraphiteSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\v' || b == '\f' || b == '\r'
+}
+
+// ParseResult holds the decoded measurement, tags and field name
+// produced by Template.ApplyBytes. Callers on a hot path should reuse
+// the same ParseResult across calls -- its buffers are reset and
+// grown as needed rather than reallocated from scratch.
+type ParseResult struct {
+	Measurement []byte
+	Tags        models.Tags
+	Field       []byte
+}
+
+// reset truncates r's buffers to zero length while keeping their
+// underlying arrays, so the next ApplyBytes call can reuse them.
+func (r *ParseResult) reset() {
+	r.Measurement = r.Measurement[:0]
+	r.Tags = r.Tags[:0]
+	r.Field = r.Field[:0]
+}
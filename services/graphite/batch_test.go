@@ -0,0 +1,65 @@
+package graphite
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// benchmarkLines builds n realistic graphite lines under a 20-component
+// dotted metric name, the kind of deeply-segmented name a host/service
+// hierarchy produces in practice.
+func benchmarkLines(n int) []byte {
+	var parts []string
+	for i := 0; i < 20; i++ {
+		parts = append(parts, fmt.Sprintf("part%d", i))
+	}
+	name := strings.Join(parts, ".")
+
+	var buf strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, "%s %d %d\n", name, i, 1500000000+i)
+	}
+	return []byte(buf.String())
+}
+
+func BenchmarkParse(b *testing.B) {
+	p, err := NewParser(nil, nil)
+	if err != nil {
+		b.Fatalf("NewParser failed: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(benchmarkLines(1000)), "\n"), "\n")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			if _, err := p.Parse(line); err != nil {
+				b.Fatalf("Parse failed: %s", err)
+			}
+		}
+	}
+}
+
+func BenchmarkParseLines(b *testing.B) {
+	p, err := NewParser(nil, nil)
+	if err != nil {
+		b.Fatalf("NewParser failed: %s", err)
+	}
+
+	buf := benchmarkLines(1000)
+	out := make([]models.Point, 0, 1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var err error
+		out, err = p.ParseLines(buf, out[:0])
+		if err != nil {
+			b.Fatalf("ParseLines failed: %s", err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package graphite
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// dropwizardCategory describes one of the top-level metric groups in a
+// Dropwizard metrics registry JSON payload and the "metric_type" tag
+// that should be applied to points decoded from it.
+type dropwizardCategory struct {
+	key      string
+	tagValue string
+}
+
+var dropwizardCategories = []dropwizardCategory{
+	{key: "counters", tagValue: "counter"},
+	{key: "gauges", tagValue: "gauge"},
+	{key: "meters", tagValue: "meter"},
+	{key: "histograms", tagValue: "histogram"},
+	{key: "timers", tagValue: "timer"},
+}
+
+// DropwizardParser decodes a Dropwizard metrics registry JSON payload
+// (the format served by metrics-servlets and most Dropwizard JSON
+// reporters) into models.Points, running each JSON metric key through
+// the same template matcher used for dotted graphite line-protocol
+// metrics so a single `templates = [...]` configuration covers both.
+type DropwizardParser struct {
+	parser *Parser
+
+	// MetricsPath is a dotted path to the object within the payload that
+	// holds the "counters", "gauges", "meters", "histograms" and
+	// "timers" groups. Empty means the groups are at the payload's top
+	// level.
+	MetricsPath string
+
+	// TimePath is a dotted path to a field within the payload holding
+	// the timestamp to apply to every decoded point, either a Unix time
+	// in seconds or an RFC3339 string. Empty means use the current time.
+	TimePath string
+}
+
+// NewDropwizardParser returns a DropwizardParser that decodes metrics
+// using the templates and tags configured on p.
+func NewDropwizardParser(p *Parser) *DropwizardParser {
+	return &DropwizardParser{parser: p}
+}
+
+// Parse decodes a Dropwizard metrics registry JSON payload into points.
+func (dp *DropwizardParser) Parse(data []byte) ([]models.Point, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("graphite dropwizard: %s", err)
+	}
+
+	timestamp := time.Now().UTC()
+	if dp.TimePath != "" {
+		v, ok := resolveJSONPath(raw, dp.TimePath)
+		if !ok {
+			return nil, fmt.Errorf("graphite dropwizard: time_path %q not found", dp.TimePath)
+		}
+		t, err := dropwizardTimestamp(v)
+		if err != nil {
+			return nil, fmt.Errorf("graphite dropwizard: time_path %q: %s", dp.TimePath, err)
+		}
+		timestamp = t
+	}
+
+	root := raw
+	if dp.MetricsPath != "" {
+		v, ok := resolveJSONPath(raw, dp.MetricsPath)
+		if !ok {
+			return nil, fmt.Errorf("graphite dropwizard: metrics_path %q not found", dp.MetricsPath)
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("graphite dropwizard: metrics_path %q is not an object", dp.MetricsPath)
+		}
+		root = m
+	}
+
+	var points []models.Point
+	for _, category := range dropwizardCategories {
+		group, ok := root[category.key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for name, v := range group {
+			metric, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			point, err := dp.point(name, category.tagValue, metric, timestamp)
+			if err != nil {
+				return nil, err
+			}
+			if point != nil {
+				points = append(points, point)
+			}
+		}
+	}
+	return points, nil
+}
+
+// point builds a models.Point for a single Dropwizard metric, running
+// its name through the configured matcher exactly like a graphite
+// dotted path and emitting every numeric sub-field (e.g. "p50", "p99",
+// "mean", "count") of its JSON object as a field on the point.
+func (dp *DropwizardParser) point(name, metricType string, metric map[string]interface{}, timestamp time.Time) (models.Point, error) {
+	template := dp.parser.matcher.Match(name)
+	measurement, tags, _, err := template.Apply(name)
+	if err != nil {
+		return nil, err
+	}
+	if measurement == "" {
+		measurement = name
+	}
+	tags["metric_type"] = metricType
+
+	for _, t := range dp.parser.tags {
+		if _, ok := tags[string(t.Key)]; !ok {
+			tags[string(t.Key)] = string(t.Value)
+		}
+	}
+
+	fields := map[string]interface{}{}
+	for k, v := range metric {
+		if n, ok := v.(float64); ok {
+			fields[k] = n
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	return models.NewPoint(measurement, models.NewTags(tags), fields, timestamp)
+}
+
+// resolveJSONPath walks a "."-separated path of object keys through a
+// decoded JSON object, as produced by encoding/json's default
+// map[string]interface{} unmarshaling.
+func resolveJSONPath(root map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = root
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		v, ok := m[part]
+		if !ok {
+			return nil, false
+		}
+		cur = v
+	}
+	return cur, true
+}
+
+// dropwizardTimestamp converts a time_path value, either a Unix time in
+// seconds or an RFC3339 string, into a time.Time.
+func dropwizardTimestamp(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case float64:
+		return pickleTimestamp(t), nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return parsed.UTC(), nil
+	default:
+		return time.Time{}, fmt.Errorf("unsupported value type %T", v)
+	}
+}
@@ -0,0 +1,163 @@
+package graphite
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tagString returns the substring models.Point.String() would render for
+// a tag with the given key and value, so tests can check for a tag's
+// presence without depending on Tags having any particular order.
+func tagString(key, value string) string {
+	return fmt.Sprintf("%s=%s", key, value)
+}
+
+func TestDropwizardParser_DecodesEachCategoryWithMetricTypeTag(t *testing.T) {
+	p, err := NewParser(nil, nil)
+	if err != nil {
+		t.Fatalf("NewParser failed: %s", err)
+	}
+	dp := NewDropwizardParser(p)
+
+	payload := []byte(`{
+		"counters": {"requests": {"count": 42}},
+		"gauges": {"heap.used": {"value": 123.5}}
+	}`)
+
+	points, err := dp.Parse(payload)
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("got %d points, want 2: %v", len(points), points)
+	}
+
+	counterTag := tagString("metric_type", "counter")
+	gaugeTag := tagString("metric_type", "gauge")
+	var sawCounter, sawGauge bool
+	for _, pt := range points {
+		s := pt.String()
+		switch {
+		case strings.Contains(s, counterTag):
+			sawCounter = true
+		case strings.Contains(s, gaugeTag):
+			sawGauge = true
+		}
+	}
+	if !sawCounter || !sawGauge {
+		t.Errorf("expected both a counter and a gauge point, got: %v", points)
+	}
+}
+
+func TestDropwizardParser_MetricsPathNarrowsToNestedObject(t *testing.T) {
+	p, err := NewParser(nil, nil)
+	if err != nil {
+		t.Fatalf("NewParser failed: %s", err)
+	}
+	dp := NewDropwizardParser(p)
+	dp.MetricsPath = "payload.metrics"
+
+	payload := []byte(`{"payload": {"metrics": {"counters": {"requests": {"count": 1}}}}}`)
+
+	points, err := dp.Parse(payload)
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	if len(points) != 1 {
+		t.Fatalf("got %d points, want 1", len(points))
+	}
+}
+
+func TestDropwizardParser_MetricsPathNotFoundErrors(t *testing.T) {
+	p, err := NewParser(nil, nil)
+	if err != nil {
+		t.Fatalf("NewParser failed: %s", err)
+	}
+	dp := NewDropwizardParser(p)
+	dp.MetricsPath = "nope"
+
+	if _, err := dp.Parse([]byte(`{"counters": {}}`)); err == nil {
+		t.Fatalf("expected an error for a missing metrics_path")
+	}
+}
+
+func TestDropwizardParser_TimePathAcceptsUnixAndRFC3339(t *testing.T) {
+	p, err := NewParser(nil, nil)
+	if err != nil {
+		t.Fatalf("NewParser failed: %s", err)
+	}
+
+	unix := NewDropwizardParser(p)
+	unix.TimePath = "timestamp"
+	points, err := unix.Parse([]byte(`{"timestamp": 1500000000, "counters": {"requests": {"count": 1}}}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	want := time.Date(2017, 7, 14, 2, 40, 0, 0, time.UTC)
+	if len(points) != 1 || !points[0].Time().Equal(want) {
+		t.Errorf("expected unix timestamp to decode to %s, got: %v", want, points)
+	}
+
+	rfc := NewDropwizardParser(p)
+	rfc.TimePath = "timestamp"
+	points, err = rfc.Parse([]byte(`{"timestamp": "2017-07-14T02:40:00Z", "counters": {"requests": {"count": 1}}}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	if len(points) != 1 || !points[0].Time().Equal(want) {
+		t.Errorf("expected RFC3339 timestamp to decode to %s, got: %v", want, points)
+	}
+}
+
+func TestDropwizardParser_TimePathNotFoundErrors(t *testing.T) {
+	p, err := NewParser(nil, nil)
+	if err != nil {
+		t.Fatalf("NewParser failed: %s", err)
+	}
+	dp := NewDropwizardParser(p)
+	dp.TimePath = "missing"
+
+	if _, err := dp.Parse([]byte(`{"counters": {}}`)); err == nil {
+		t.Fatalf("expected an error for a missing time_path")
+	}
+}
+
+func TestDropwizardParser_SkipsNonNumericSubFields(t *testing.T) {
+	p, err := NewParser(nil, nil)
+	if err != nil {
+		t.Fatalf("NewParser failed: %s", err)
+	}
+	dp := NewDropwizardParser(p)
+
+	payload := []byte(`{"gauges": {"status": {"value": 200, "unit": "ok", "description": "fine"}}}`)
+	points, err := dp.Parse(payload)
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	if len(points) != 1 {
+		t.Fatalf("got %d points, want 1", len(points))
+	}
+	s := points[0].String()
+	if strings.Contains(s, "unit=") || strings.Contains(s, "description=") {
+		t.Errorf("expected non-numeric sub-fields to be skipped, got: %s", s)
+	}
+}
+
+func TestDropwizardParser_SkipsMetricWithNoNumericFields(t *testing.T) {
+	p, err := NewParser(nil, nil)
+	if err != nil {
+		t.Fatalf("NewParser failed: %s", err)
+	}
+	dp := NewDropwizardParser(p)
+
+	payload := []byte(`{"gauges": {"status": {"unit": "ok"}}}`)
+	points, err := dp.Parse(payload)
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	if len(points) != 0 {
+		t.Fatalf("got %d points, want 0 for a metric with no numeric sub-fields", len(points))
+	}
+}
@@ -0,0 +1,73 @@
+package graphite
+
+import "testing"
+
+// TestMatcher_LongerWildcardFilterStaysReachableBehindShorter reproduces
+// the case this package's specificity-based ordering is meant to
+// handle: a deeper wildcard filter that extends a shallower one must
+// still win for metrics it matches, even though the shallower filter
+// is sorted first for having fewer wildcards.
+func TestMatcher_LongerWildcardFilterStaysReachableBehindShorter(t *testing.T) {
+	p, err := NewParserWithOptions(Options{
+		Separator: ".",
+		Templates: []string{
+			"*.*.* a.b.measurement",
+			"*.*.*.* a.b.c.measurement",
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewParserWithOptions failed: %s", err)
+	}
+
+	measurement, tags, _, err := p.ApplyTemplate("a.b.c.d 42 1500000000")
+	if err != nil {
+		t.Fatalf("ApplyTemplate failed: %s", err)
+	}
+
+	if measurement != "d" {
+		t.Errorf("measurement = %q, want %q (the 4-part template should win)", measurement, "d")
+	}
+	want := map[string]string{"a": "a", "b": "b", "c": "c"}
+	for k, v := range want {
+		if tags[k] != v {
+			t.Errorf("tags[%s] = %q, want %q", k, tags[k], v)
+		}
+	}
+
+	// A 3-component metric should still resolve against the shorter
+	// filter, since the deeper filter simply doesn't apply to it.
+	measurement3, _, _, err := p.ApplyTemplate("a.b.c 42 1500000000")
+	if err != nil {
+		t.Fatalf("ApplyTemplate failed: %s", err)
+	}
+	if measurement3 != "c" {
+		t.Errorf("measurement = %q, want %q (the 3-part template should win)", measurement3, "c")
+	}
+}
+
+// TestMatcher_MoreSpecificFilterWinsRegardlessOfDeclarationOrder checks
+// that the outcome of the previous test doesn't depend on the order
+// the templates were declared in, since NewParserWithOptions sorts
+// them by specificity before building the matcher tree.
+func TestMatcher_MoreSpecificFilterWinsRegardlessOfDeclarationOrder(t *testing.T) {
+	templates := []string{
+		"*.*.* a.b.measurement",
+		"*.*.*.* a.b.c.measurement",
+	}
+	reversed := []string{templates[1], templates[0]}
+
+	for _, tmpls := range [][]string{templates, reversed} {
+		p, err := NewParserWithOptions(Options{Separator: ".", Templates: tmpls})
+		if err != nil {
+			t.Fatalf("NewParserWithOptions failed: %s", err)
+		}
+
+		measurement, _, _, err := p.ApplyTemplate("a.b.c.d 42 1500000000")
+		if err != nil {
+			t.Fatalf("ApplyTemplate failed: %s", err)
+		}
+		if measurement != "d" {
+			t.Errorf("measurement = %q, want %q regardless of declaration order", measurement, "d")
+		}
+	}
+}
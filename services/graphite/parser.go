@@ -1,6 +1,7 @@
 package graphite
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math"
@@ -13,6 +14,10 @@ import (
 	"github.com/influxdata/influxdb/models"
 )
 
+// dot is the separator byte used to split a graphite metric name into
+// components, shared by the byte-oriented template and parsing paths.
+var dot = []byte(".")
+
 // Minimum and maximum supported dates for timestamps.
 var (
 	// The minimum graphite timestamp allowed.
@@ -34,8 +39,9 @@ func init() {
 
 // Parser encapsulates a Graphite Parser.
 type Parser struct {
-	matcher *matcher
-	tags    models.Tags
+	matcher          *matcher
+	tags             models.Tags
+	enableTagSupport bool
 }
 
 // Options are configurable values that can be provided to a Parser.
@@ -43,6 +49,13 @@ type Options struct {
 	Separator   string
 	Templates   interface{}
 	DefaultTags models.Tags
+
+	// EnableTagSupport enables parsing of the Graphite 1.1 tag-in-metric
+	// syntax, e.g. "disk.used;host=web01;mountpoint=/var". NewParser
+	// enables this by default; set it to false for metrics coming from a
+	// strict pre-1.1 Carbon relay, where a literal ";" in a metric name
+	// is not expected to introduce tags.
+	EnableTagSupport bool
 }
 
 // NewParserWithOptions returns a graphite parser using the given options.
@@ -52,19 +65,58 @@ func NewParserWithOptions(options Options) (*Parser, error) {
 	matcher.AddDefaultTemplate(defaultTemplate)
 
 	if options.Templates != nil {
-		templates, err := compileTemplates(options.Templates, options)
+		entries, err := compileTemplates(options.Templates, options)
 		if err != nil {
 			return nil, err
 		}
 
-		for filter, template := range templates {
-			matcher.Add(filter, template)
+		// Put the most specific filters first so that, all else being
+		// equal, earlier declarations win ties in the match tree. Each
+		// entry's specificity is computed from its original declaration
+		// position before sorting begins -- the i/j passed to Less are
+		// transient slot indexes into the slice being reordered, not an
+		// entry's true position, so computing specificity from them
+		// inside the comparator would make ties depend on the sort's
+		// internal comparison order.
+		specs := make([]specificity, len(entries))
+		for i, e := range entries {
+			specs[i] = entrySpecificity(e, i)
+		}
+		sort.Stable(bySpecificity{entries: entries, specs: specs})
+
+		for _, e := range entries {
+			matcher.Add(e.filter, e.template)
 		}
 	}
-	return &Parser{matcher: matcher, tags: options.DefaultTags}, nil
+	return &Parser{matcher: matcher, tags: options.DefaultTags, enableTagSupport: options.EnableTagSupport}, nil
+}
+
+// templateEntry pairs a compiled template with the filter it was
+// declared under, preserving the order in which it appeared in the
+// configuration.
+type templateEntry struct {
+	filter   string
+	template Template
+}
+
+// bySpecificity sorts entries by their precomputed specs, keeping the
+// two slices in lockstep so each entry's specificity stays tied to its
+// original declaration position rather than being recomputed mid-sort.
+type bySpecificity struct {
+	entries []templateEntry
+	specs   []specificity
+}
+
+func (b bySpecificity) Len() int { return len(b.entries) }
+func (b bySpecificity) Less(i, j int) bool {
+	return b.specs[i].wins(b.specs[j])
+}
+func (b bySpecificity) Swap(i, j int) {
+	b.entries[i], b.entries[j] = b.entries[j], b.entries[i]
+	b.specs[i], b.specs[j] = b.specs[j], b.specs[i]
 }
 
-func compileTemplates(templates interface{}, options Options) (map[string]Template, error) {
+func compileTemplates(templates interface{}, options Options) ([]templateEntry, error) {
 	switch templates := templates.(type) {
 	case []interface{}:
 		// Every value inside of this interface should be a string.
@@ -78,7 +130,7 @@ func compileTemplates(templates interface{}, options Options) (map[string]Templa
 		}
 		return compileTemplates(clone, options)
 	case []string:
-		tmpls := make(map[string]Template, len(templates))
+		tmpls := make([]templateEntry, 0, len(templates))
 		for _, pattern := range templates {
 			template := pattern
 			filter := ""
@@ -109,11 +161,11 @@ func compileTemplates(templates interface{}, options Options) (map[string]Templa
 			if err != nil {
 				return nil, err
 			}
-			tmpls[filter] = tmpl
+			tmpls = append(tmpls, templateEntry{filter: filter, template: tmpl})
 		}
 		return tmpls, nil
 	case []map[string]interface{}:
-		tmpls := make(map[string]Template, len(templates))
+		tmpls := make([]templateEntry, 0, len(templates))
 		for _, spec := range templates {
 			var template string
 			if v, ok := spec["template"]; !ok {
@@ -182,7 +234,7 @@ func compileTemplates(templates interface{}, options Options) (map[string]Templa
 					return nil, fmt.Errorf("filter must be a string")
 				}
 			}
-			tmpls[filter] = tmpl
+			tmpls = append(tmpls, templateEntry{filter: filter, template: tmpl})
 		}
 		return tmpls, nil
 	default:
@@ -194,30 +246,52 @@ func compileTemplates(templates interface{}, options Options) (map[string]Templa
 func NewParser(templates []string, defaultTags models.Tags) (*Parser, error) {
 	return NewParserWithOptions(
 		Options{
-			Templates:   templates,
-			DefaultTags: defaultTags,
-			Separator:   DefaultSeparator,
+			Templates:        templates,
+			DefaultTags:      defaultTags,
+			Separator:        DefaultSeparator,
+			EnableTagSupport: true,
 		})
 }
 
 // Parse performs Graphite parsing of a single line.
 func (p *Parser) Parse(line string) (models.Point, error) {
-	// Break into 3 fields (name, value, timestamp).
-	fields := strings.Fields(line)
+	return p.parseFields(strings.Fields(line))
+}
+
+// parseFields builds a point from a graphite line that has already been
+// split into whitespace-delimited fields (name, value, and an optional
+// timestamp). It is the implementation behind Parse; ParseLines uses
+// the byte-oriented parseFieldsBytes instead.
+func (p *Parser) parseFields(fields []string) (models.Point, error) {
 	if len(fields) != 2 && len(fields) != 3 {
-		return nil, fmt.Errorf("received %q which doesn't have required fields", line)
+		return nil, fmt.Errorf("received %q which doesn't have required fields", strings.Join(fields, " "))
 	}
 
+	// Split off any Graphite 1.1 tags appended to the metric name before
+	// handing the bare dotted path to the template.
+	name, tagString := splitMetricTags(fields[0], p.enableTagSupport)
+
 	// decode the name and tags
-	template := p.matcher.Match(fields[0])
-	measurement, tags, field, err := template.Apply(fields[0])
+	template := p.matcher.Match(name)
+	measurement, tags, field, err := template.Apply(name)
 	if err != nil {
 		return nil, err
 	}
 
 	// Could not extract measurement, use the raw value
 	if measurement == "" {
-		measurement = fields[0]
+		measurement = name
+	}
+
+	if tagString != "" {
+		metricTags, err := parseMetricTags(tagString)
+		if err != nil {
+			return nil, fmt.Errorf(`field "%s" tags: %s`, fields[0], err)
+		}
+		// Semicolon tags are explicit and win over template-derived tags.
+		for k, v := range metricTags {
+			tags[k] = v
+		}
 	}
 
 	// Parse value.
@@ -267,6 +341,97 @@ func (p *Parser) Parse(line string) (models.Point, error) {
 	return models.NewPoint(measurement, models.NewTags(tags), fieldValues, timestamp)
 }
 
+// parseFieldsBytes is the byte-oriented counterpart to parseFields used
+// by ParseLines. It decodes the metric name through the matcher's
+// ApplyBytes path instead of the map/strings.Join-based Apply, reusing
+// dst across calls so that repeated lines don't pay for an intermediate
+// tags map on every point. dst's buffers are invalidated by the next
+// call, so the measurement, tags and field values are copied out into
+// freshly allocated storage before being handed to models.NewPoint.
+func (p *Parser) parseFieldsBytes(fields [][]byte, dst *ParseResult) (models.Point, error) {
+	if len(fields) != 2 && len(fields) != 3 {
+		return nil, fmt.Errorf("received %q which doesn't have required fields", bytes.Join(fields, []byte(" ")))
+	}
+
+	// Split off any Graphite 1.1 tags appended to the metric name before
+	// handing the bare dotted path to the template.
+	name, tagField := splitMetricTagsBytes(fields[0], p.enableTagSupport)
+
+	template := p.matcher.Match(string(name))
+	if err := template.ApplyBytes(name, dst); err != nil {
+		return nil, err
+	}
+
+	measurement := string(dst.Measurement)
+	if measurement == "" {
+		measurement = string(name)
+	}
+
+	tags := make(models.Tags, len(dst.Tags))
+	for i, t := range dst.Tags {
+		tags[i] = models.Tag{Key: append([]byte(nil), t.Key...), Value: append([]byte(nil), t.Value...)}
+	}
+
+	if len(tagField) > 0 {
+		metricTags, err := parseMetricTags(string(tagField))
+		if err != nil {
+			return nil, fmt.Errorf(`field "%s" tags: %s`, name, err)
+		}
+		// Semicolon tags are explicit and win over template-derived tags.
+		for k, v := range metricTags {
+			if j := findTag(tags, []byte(k)); j >= 0 {
+				tags[j].Value = []byte(v)
+			} else {
+				tags = append(tags, models.Tag{Key: []byte(k), Value: []byte(v)})
+			}
+		}
+	}
+
+	field := string(dst.Field)
+
+	v, err := strconv.ParseFloat(string(fields[1]), 64)
+	if err != nil {
+		return nil, fmt.Errorf(`field "%s" value: %s`, name, err)
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, &UnsupportedValueError{Field: string(name), Value: v}
+	}
+
+	fieldValues := map[string]interface{}{}
+	if field != "" {
+		fieldValues[field] = v
+	} else {
+		fieldValues["value"] = v
+	}
+
+	// If no 3rd field, use now as timestamp
+	timestamp := time.Now().UTC()
+	if len(fields) == 3 {
+		unixTime, err := strconv.ParseFloat(string(fields[2]), 64)
+		if err != nil {
+			return nil, fmt.Errorf(`field "%s" time: %s`, name, err)
+		}
+		// -1 is a special value that gets converted to current UTC time
+		// See https://github.com/graphite-project/carbon/issues/54
+		if unixTime != float64(-1) {
+			timestamp = time.Unix(int64(unixTime), int64((unixTime-math.Floor(unixTime))*float64(time.Second)))
+			if timestamp.Before(MinDate) || timestamp.After(MaxDate) {
+				return nil, fmt.Errorf("timestamp out of range")
+			}
+		}
+	}
+
+	// Set the default tags on the point if they are not already set
+	for _, t := range p.tags {
+		if findTag(tags, t.Key) < 0 {
+			tags = append(tags, models.Tag{Key: append([]byte(nil), t.Key...), Value: append([]byte(nil), t.Value...)})
+		}
+	}
+	sort.Sort(tags)
+
+	return models.NewPoint(measurement, tags, fieldValues, timestamp)
+}
+
 // ApplyTemplate extracts the template fields from the given line and
 // returns the measurement name and tags.
 func (p *Parser) ApplyTemplate(line string) (string, map[string]string, string, error) {
@@ -275,9 +440,28 @@ func (p *Parser) ApplyTemplate(line string) (string, map[string]string, string,
 	if len(fields) == 0 {
 		return "", make(map[string]string), "", nil
 	}
+	// Split off any Graphite 1.1 tags appended to the metric name before
+	// handing the bare dotted path to the template.
+	metric, tagString := splitMetricTags(fields[0], p.enableTagSupport)
+
 	// decode the name and tags
-	template := p.matcher.Match(fields[0])
-	name, tags, field, err := template.Apply(fields[0])
+	template := p.matcher.Match(metric)
+	name, tags, field, err := template.Apply(metric)
+	if err != nil {
+		return name, tags, field, err
+	}
+
+	if tagString != "" {
+		metricTags, err := parseMetricTags(tagString)
+		if err != nil {
+			return "", nil, "", fmt.Errorf(`field "%s" tags: %s`, fields[0], err)
+		}
+		// Semicolon tags are explicit and win over template-derived tags.
+		for k, v := range metricTags {
+			tags[k] = v
+		}
+	}
+
 	// Set the default tags on the point if they are not already set
 	for _, t := range p.tags {
 		if _, ok := tags[string(t.Key)]; !ok {
@@ -287,13 +471,70 @@ func (p *Parser) ApplyTemplate(line string) (string, map[string]string, string,
 	return name, tags, field, err
 }
 
+// splitMetricTags splits a Graphite 1.1 "metric;tag=value;tag2=value2" name
+// on its first ";" and returns the bare dotted metric path and the raw tag
+// string (without the leading ";"). If tag support is disabled or the
+// metric has no ";", the tag string is empty and name is returned as-is.
+func splitMetricTags(name string, enabled bool) (string, string) {
+	if !enabled {
+		return name, ""
+	}
+	if i := strings.IndexByte(name, ';'); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
+
+// splitMetricTagsBytes is the byte-oriented counterpart to
+// splitMetricTags, used by the allocation-conscious ParseLines path.
+func splitMetricTagsBytes(name []byte, enabled bool) ([]byte, []byte) {
+	if !enabled {
+		return name, nil
+	}
+	if i := bytes.IndexByte(name, ';'); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, nil
+}
+
+// parseMetricTags parses and validates a Graphite 1.1 tag string of the
+// form "key=value;key2=value2" as found after the first ";" in a tagged
+// metric name. Per the Graphite spec, keys and values must be non-empty
+// and may not contain ";" or "=".
+func parseMetricTags(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, pair := range strings.Split(s, ";") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid tag %q, expected key=value", pair)
+		}
+		key, value := kv[0], kv[1]
+		if key == "" || value == "" {
+			return nil, fmt.Errorf("invalid tag %q, key and value must be non-empty", pair)
+		}
+		if strings.ContainsAny(key, ";=") || strings.ContainsAny(value, ";=") {
+			return nil, fmt.Errorf("invalid tag %q, key and value may not contain ';' or '='", pair)
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
+
 type Template interface {
 	Apply(line string) (string, map[string]string, string, error)
+
+	// ApplyBytes is the allocation-conscious counterpart to Apply: it
+	// decodes line directly into dst's preallocated buffers instead of
+	// returning freshly allocated strings and maps, so a caller that
+	// reuses the same *ParseResult across many calls can amortize its
+	// allocations across a whole connection's worth of metrics.
+	ApplyBytes(line []byte, dst *ParseResult) error
 }
 
 // simpleTemplate represents a pattern and tags to map a graphite metric string to a influxdb Point.
 type simpleTemplate struct {
 	tags              []string
+	tagKeys           [][]byte
 	defaultTags       models.Tags
 	greedyMeasurement bool
 	separator         string
@@ -304,15 +545,20 @@ type simpleTemplate struct {
 func NewTemplate(pattern string, defaultTags models.Tags, separator string) (Template, error) {
 	tags := strings.Split(pattern, ".")
 	hasMeasurement := false
-	template := &simpleTemplate{tags: tags, defaultTags: defaultTags, separator: separator}
+	template := &simpleTemplate{tags: tags, tagKeys: make([][]byte, len(tags)), defaultTags: defaultTags, separator: separator}
 
-	for _, tag := range tags {
+	for i, tag := range tags {
 		if strings.HasPrefix(tag, "measurement") {
 			hasMeasurement = true
 		}
 		if tag == "measurement*" {
 			template.greedyMeasurement = true
 		}
+		if tag != "" && tag != "measurement" && tag != "measurement*" && tag != "field" && tag != "field*" {
+			// Precompute the []byte form of each tag name so ApplyBytes
+			// doesn't have to convert it on every call.
+			template.tagKeys[i] = []byte(tag)
+		}
 	}
 
 	if !hasMeasurement {
@@ -329,7 +575,7 @@ func (t *simpleTemplate) Apply(line string) (string, map[string]string, string,
 	var (
 		measurement            []string
 		tags                   = make(map[string][]string)
-		field                  string
+		field                  []string
 		hasFieldWildcard       = false
 		hasMeasurementWildcard = false
 	)
@@ -359,12 +605,13 @@ func (t *simpleTemplate) Apply(line string) (string, map[string]string, string,
 		if tag == "measurement" {
 			measurement = append(measurement, fields[i])
 		} else if tag == "field" {
-			if len(field) != 0 {
-				return "", nil, "", fmt.Errorf("'field' can only be used once in each template: %q", line)
-			}
-			field = fields[i]
+			// Multiple 'field' parts are joined with the separator to
+			// build a compound field name, e.g. with separator "_",
+			// "field.field" applied to "idle.percent" yields the field
+			// "idle_percent".
+			field = append(field, fields[i])
 		} else if tag == "field*" {
-			field = strings.Join(fields[i:], t.separator)
+			field = append(field, fields[i:]...)
 			break
 		} else if tag == "measurement*" {
 			measurement = append(measurement, fields[i:]...)
@@ -380,7 +627,100 @@ func (t *simpleTemplate) Apply(line string) (string, map[string]string, string,
 		out_tags[k] = strings.Join(values, t.separator)
 	}
 
-	return strings.Join(measurement, t.separator), out_tags, field, nil
+	return strings.Join(measurement, t.separator), out_tags, strings.Join(field, t.separator), nil
+}
+
+// ApplyBytes is the byte-oriented counterpart to Apply. It decodes line
+// into dst's reusable buffers instead of allocating a fresh measurement
+// string, tags map, and field string on every call.
+func (t *simpleTemplate) ApplyBytes(line []byte, dst *ParseResult) error {
+	dst.reset()
+
+	var hasFieldWildcard, hasMeasurementWildcard bool
+	for _, tag := range t.tags {
+		if tag == "measurement*" {
+			hasMeasurementWildcard = true
+		} else if tag == "field*" {
+			hasFieldWildcard = true
+		}
+	}
+	if hasFieldWildcard && hasMeasurementWildcard {
+		return fmt.Errorf("either 'field*' or 'measurement*' can be used in each template (but not both together): %q", strings.Join(t.tags, t.separator))
+	}
+
+	dst.Tags = append(dst.Tags, t.defaultTags...)
+
+	sep := t.separator
+	components := bytes.Split(line, dot)
+	var haveMeasurement, haveField bool
+
+	for idx, tag := range t.tags {
+		if idx >= len(components) {
+			continue
+		}
+		component := components[idx]
+
+		if tag == "measurement" {
+			if haveMeasurement {
+				dst.Measurement = append(dst.Measurement, sep...)
+			}
+			dst.Measurement = append(dst.Measurement, component...)
+			haveMeasurement = true
+		} else if tag == "field" {
+			if haveField {
+				dst.Field = append(dst.Field, sep...)
+			}
+			dst.Field = append(dst.Field, component...)
+			haveField = true
+		} else if tag == "field*" {
+			for k, rest := range components[idx:] {
+				if haveField || k > 0 {
+					dst.Field = append(dst.Field, sep...)
+				}
+				dst.Field = append(dst.Field, rest...)
+				haveField = true
+			}
+			break
+		} else if tag == "measurement*" {
+			for k, rest := range components[idx:] {
+				if haveMeasurement || k > 0 {
+					dst.Measurement = append(dst.Measurement, sep...)
+				}
+				dst.Measurement = append(dst.Measurement, rest...)
+				haveMeasurement = true
+			}
+			break
+		} else if tag != "" {
+			// A tag name repeated across the template's own parts (or one
+			// that collides with a default tag already seeded into
+			// dst.Tags above) is joined with the separator, matching
+			// Apply's map-based accumulation, rather than producing two
+			// Tags entries with the same key.
+			key := t.tagKeys[idx]
+			if j := findTag(dst.Tags, key); j >= 0 {
+				merged := make([]byte, 0, len(dst.Tags[j].Value)+len(sep)+len(component))
+				merged = append(merged, dst.Tags[j].Value...)
+				merged = append(merged, sep...)
+				merged = append(merged, component...)
+				dst.Tags[j].Value = merged
+			} else {
+				dst.Tags = append(dst.Tags, models.Tag{Key: key, Value: component})
+			}
+		}
+	}
+
+	return nil
+}
+
+// findTag returns the index of the Tag in tags whose Key matches key, or
+// -1 if there is none.
+func findTag(tags models.Tags, key []byte) int {
+	for i := range tags {
+		if bytes.Equal(tags[i].Key, key) {
+			return i
+		}
+	}
+	return -1
 }
 
 type regexpTemplate struct {
@@ -439,11 +779,99 @@ func (t *regexpTemplate) Apply(line string) (string, map[string]string, string,
 	return measurement, tags, field, nil
 }
 
+// ApplyBytes is the byte-oriented counterpart to Apply.
+func (t *regexpTemplate) ApplyBytes(line []byte, dst *ParseResult) error {
+	dst.reset()
+
+	m := t.re.FindSubmatch(line)
+	if m == nil {
+		return fmt.Errorf("unable to match '%s' to regular expression /%s/", line, t.re.String())
+	}
+
+	for i, name := range t.re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+
+		switch name {
+		case "measurement":
+			dst.Measurement = append(dst.Measurement, m[i]...)
+		case "field":
+			dst.Field = append(dst.Field, m[i]...)
+		default:
+			dst.Tags = append(dst.Tags, models.Tag{Key: []byte(name), Value: m[i]})
+		}
+	}
+	if len(dst.Field) == 0 {
+		dst.Field = append(dst.Field, "value"...)
+	}
+	return nil
+}
+
+// specificity captures how specific a filter is, so that when more than
+// one registered filter could apply to the same node in the match tree,
+// the most specific one wins instead of whichever was inserted last.
+// Filters are ranked by (1) the number of leading exact (non-wildcard)
+// components, (2) the fewest wildcards overall, and (3) order, where
+// the higher order wins a tie. NewParserWithOptions sorts tied entries
+// in reverse declaration order before adding them, so that the earliest
+// declaration ends up with the highest order and wins.
+type specificity struct {
+	exactPrefix int
+	wildcards   int
+	order       int
+}
+
+// entrySpecificity computes the specificity of a configured template
+// entry. A filterless ("") entry is treated as the least specific of
+// all, since it applies as the catch-all default rather than competing
+// for a spot in the filter tree.
+func entrySpecificity(e templateEntry, order int) specificity {
+	if e.filter == "" {
+		return specificity{exactPrefix: -1, order: order}
+	}
+	return filterSpecificity(strings.Split(e.filter, "."), order)
+}
+
+// filterSpecificity computes the specificity of a filter's dot-separated
+// parts. order should be the filter's position in the (post-sort)
+// declaration sequence, used only to break ties between two filters that
+// are otherwise equally specific.
+func filterSpecificity(parts []string, order int) specificity {
+	var s specificity
+	s.order = order
+	seenWildcard := false
+	for _, p := range parts {
+		if p == "*" {
+			s.wildcards++
+			seenWildcard = true
+		} else if !seenWildcard {
+			s.exactPrefix++
+		}
+	}
+	return s
+}
+
+// wins reports whether s should replace other as the template attached
+// to a given tree node.
+func (s specificity) wins(other specificity) bool {
+	if s.exactPrefix != other.exactPrefix {
+		return s.exactPrefix > other.exactPrefix
+	}
+	if s.wildcards != other.wildcards {
+		return s.wildcards < other.wildcards
+	}
+	return s.order >= other.order
+}
+
 // matcher determines which template should be applied to a given metric
-// based on a filter tree.
+// based on a filter tree. Every distinct filter added via Add is
+// retained; of two identical filters, only the one that wins (see
+// specificity) is kept.
 type matcher struct {
 	root            *node
 	defaultTemplate Template
+	nextOrder       int
 }
 
 func newMatcher() *matcher {
@@ -452,13 +880,18 @@ func newMatcher() *matcher {
 	}
 }
 
-// Add inserts the template in the filter tree based the given filter.
+// Add inserts the template in the filter tree based the given filter. If
+// another template was already registered for the same filter, the two
+// are equally specific and the one added later wins; see specificity.
 func (m *matcher) Add(filter string, template Template) {
 	if filter == "" {
 		m.AddDefaultTemplate(template)
 		return
 	}
-	m.root.Insert(filter, template)
+	parts := strings.Split(filter, ".")
+	spec := filterSpecificity(parts, m.nextOrder)
+	m.nextOrder++
+	m.root.Insert(parts, template, spec)
 }
 
 func (m *matcher) AddDefaultTemplate(template Template) {
@@ -481,12 +914,30 @@ type node struct {
 	value    string
 	children nodes
 	template Template
+	spec     specificity
+	// declared reports whether template was explicitly registered for
+	// the filter that ends at this exact node, as opposed to merely
+	// inherited from a wildcard parent when this node was created. Only
+	// a declared template is a real competitor for specificity.wins:
+	// an inherited placeholder must always yield to whatever filter
+	// actually terminates here.
+	declared bool
 }
 
-func (n *node) insert(values []string, template Template) {
-	// Add the end, set the template
+func (n *node) insert(values []string, template Template, spec specificity) {
+	// At the end of the path: this template applies here. If another
+	// filter was already explicitly declared at this exact node (as
+	// opposed to a template this node merely inherited from a wildcard
+	// parent when it was created), keep whichever is more specific
+	// rather than blindly overwriting it. A node that only ever
+	// inherited a template always loses to the filter that actually
+	// terminates here.
 	if len(values) == 0 {
-		n.template = template
+		if !n.declared || spec.wins(n.spec) {
+			n.template = template
+			n.spec = spec
+			n.declared = true
+		}
 		return
 	}
 
@@ -494,7 +945,7 @@ func (n *node) insert(values []string, template Template) {
 	// into that sub-tree
 	for _, v := range n.children {
 		if v.value == values[0] {
-			v.insert(values[1:], template)
+			v.insert(values[1:], template, spec)
 			return
 		}
 	}
@@ -504,19 +955,24 @@ func (n *node) insert(values []string, template Template) {
 	n.children = append(n.children, newNode)
 	sort.Sort(&n.children)
 
-	// Inherit template if value is wildcard
+	// Inherit template if value is wildcard. This is only a fallback for
+	// lines that stop short of a deeper filter also registered under
+	// this node; it is not itself declared here, so it must not block a
+	// more specific filter from being inserted at this new node later.
 	if values[0] == "*" {
 		newNode.template = n.template
+		newNode.spec = n.spec
+		newNode.declared = false
 	}
 
 	// Now insert the rest of the tree into the new element
-	newNode.insert(values[1:], template)
+	newNode.insert(values[1:], template, spec)
 }
 
-// Insert inserts the given string template into the tree.  The filter string is separated
-// on "." and each part is used as the path in the tree.
-func (n *node) Insert(filter string, template Template) {
-	n.insert(strings.Split(filter, "."), template)
+// Insert inserts the given template's path into the tree, keyed by the
+// filter's dot-separated parts.
+func (n *node) Insert(parts []string, template Template, spec specificity) {
+	n.insert(parts, template, spec)
 }
 
 func (n *node) search(lineParts []string) Template {
@@ -0,0 +1,123 @@
+package graphite
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+func TestParseLines_MatchesParseForTaggedTemplate(t *testing.T) {
+	p, err := NewParserWithOptions(Options{
+		Separator: "_",
+		Templates: []string{"measurement.region.host"},
+	})
+	if err != nil {
+		t.Fatalf("NewParserWithOptions failed: %s", err)
+	}
+
+	line := "cpu.us-west.host01 42 1500000000"
+
+	want, err := p.Parse(line)
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+
+	got, err := p.ParseLines([]byte(line+"\n"), nil)
+	if err != nil {
+		t.Fatalf("ParseLines failed: %s", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d points, want 1", len(got))
+	}
+	if got[0].String() != want.String() {
+		t.Errorf("ParseLines point = %q, want %q (from Parse)", got[0].String(), want.String())
+	}
+}
+
+func TestParseLines_ReusesParseResultAcrossLines(t *testing.T) {
+	p, err := NewParserWithOptions(Options{
+		Separator: "_",
+		Templates: []string{"measurement.region.host"},
+	})
+	if err != nil {
+		t.Fatalf("NewParserWithOptions failed: %s", err)
+	}
+
+	lines := []string{"cpu.us-west.host01 1 1500000000", "cpu.us-east.host02 2 1500000001"}
+	var buf strings.Builder
+	for _, line := range lines {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+
+	points, err := p.ParseLines([]byte(buf.String()), nil)
+	if err != nil {
+		t.Fatalf("ParseLines failed: %s", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("got %d points, want 2", len(points))
+	}
+
+	// The two points must remain independently correct even though both
+	// were decoded through the same reused ParseResult -- the second
+	// line's decode must not have clobbered the first point's buffers.
+	for i, line := range lines {
+		want, err := p.Parse(line)
+		if err != nil {
+			t.Fatalf("Parse failed: %s", err)
+		}
+		if points[i].String() != want.String() {
+			t.Errorf("points[%d] = %q, want %q (from Parse)", i, points[i].String(), want.String())
+		}
+	}
+}
+
+func TestTemplateApplyBytes_RepeatedTagPartsJoinValues(t *testing.T) {
+	tmpl, err := NewTemplate("measurement.region.region", nil, "_")
+	if err != nil {
+		t.Fatalf("NewTemplate failed: %s", err)
+	}
+
+	var dst ParseResult
+	if err := tmpl.ApplyBytes([]byte("cpu.us.west"), &dst); err != nil {
+		t.Fatalf("ApplyBytes failed: %s", err)
+	}
+
+	if len(dst.Tags) != 1 {
+		t.Fatalf("got %d tags, want 1 (repeated 'region' parts should merge): %v", len(dst.Tags), dst.Tags)
+	}
+	if got, want := string(dst.Tags[0].Value), "us_west"; got != want {
+		t.Errorf("tags[region] = %q, want %q", got, want)
+	}
+
+	// Apply must agree with ApplyBytes on the merged value.
+	_, tags, _, err := tmpl.Apply("cpu.us.west")
+	if err != nil {
+		t.Fatalf("Apply failed: %s", err)
+	}
+	if tags["region"] != "us_west" {
+		t.Errorf("Apply tags[region] = %q, want %q", tags["region"], "us_west")
+	}
+}
+
+func TestApplyBytes_DuplicateKeyNeverProducedInTags(t *testing.T) {
+	tmpl, err := NewTemplate("measurement.region.region", models.Tags{{Key: []byte("region"), Value: []byte("default")}}, "_")
+	if err != nil {
+		t.Fatalf("NewTemplate failed: %s", err)
+	}
+
+	var dst ParseResult
+	if err := tmpl.ApplyBytes([]byte("cpu.us.west"), &dst); err != nil {
+		t.Fatalf("ApplyBytes failed: %s", err)
+	}
+
+	seen := map[string]bool{}
+	for _, tag := range dst.Tags {
+		key := string(tag.Key)
+		if seen[key] {
+			t.Fatalf("tag key %q produced more than once: %v", key, dst.Tags)
+		}
+		seen[key] = true
+	}
+}
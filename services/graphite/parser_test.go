@@ -0,0 +1,53 @@
+package graphite
+
+import "testing"
+
+func TestTemplateApply_RepeatedFieldPartsJoinIntoCompoundField(t *testing.T) {
+	tmpl, err := NewTemplate("measurement.measurement.field.field.region", nil, "_")
+	if err != nil {
+		t.Fatalf("NewTemplate failed: %s", err)
+	}
+
+	measurement, tags, field, err := tmpl.Apply("cpu.usage.idle.percent.us-west")
+	if err != nil {
+		t.Fatalf("Apply failed: %s", err)
+	}
+
+	if measurement != "cpu_usage" {
+		t.Errorf("measurement = %q, want %q", measurement, "cpu_usage")
+	}
+	if field != "idle_percent" {
+		t.Errorf("field = %q, want %q", field, "idle_percent")
+	}
+	if tags["region"] != "us-west" {
+		t.Errorf("tags[region] = %q, want %q", tags["region"], "us-west")
+	}
+}
+
+func TestTemplateApply_FieldAndMeasurementWildcardAreMutuallyExclusive(t *testing.T) {
+	tmpl, err := NewTemplate("measurement.field*", nil, ".")
+	if err != nil {
+		t.Fatalf("NewTemplate failed: %s", err)
+	}
+
+	// NewTemplate accepts a template string like "measurement*.field*"
+	// that uses both wildcards; the conflict is reported by Apply.
+	tmpl2, err := NewTemplate("measurement*.field*", nil, ".")
+	if err != nil {
+		t.Fatalf("NewTemplate failed: %s", err)
+	}
+	if _, _, _, err := tmpl2.Apply("a.b.c"); err == nil {
+		t.Fatalf("expected an error combining 'field*' and 'measurement*' in one template")
+	}
+
+	if _, _, _, err := tmpl.Apply("cpu.idle.percent"); err != nil {
+		t.Fatalf("Apply failed: %s", err)
+	}
+}
+
+func TestNewTemplate_RequiresMeasurement(t *testing.T) {
+	if _, err := NewTemplate("region.host", nil, "."); err == nil {
+		t.Fatalf("expected an error for a template with no measurement")
+	}
+}
+
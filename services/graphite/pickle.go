@@ -0,0 +1,459 @@
+package graphite
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+// DefaultMaxPickleLength is the default maximum size, in bytes, that a
+// single pickle-protocol batch is allowed to declare in its length
+// prefix. Batches larger than this are rejected before any memory is
+// allocated for them.
+const DefaultMaxPickleLength = 64 * 1024 * 1024
+
+// PickleParser decodes Carbon's pickle-protocol batch format, as emitted
+// by carbon-relay and carbon-c-relay, and converts the decoded metrics
+// into models.Points using the same template matcher as the line
+// protocol Parser.
+type PickleParser struct {
+	parser *Parser
+
+	// MaxPayloadBytes is the largest pickle payload, in bytes, that will
+	// be decoded. Length prefixes that declare more than this are
+	// rejected before the payload is read, to avoid memory exhaustion
+	// from a malformed or hostile length prefix. Defaults to
+	// DefaultMaxPickleLength.
+	MaxPayloadBytes int
+}
+
+// NewPickleParser returns a PickleParser that decodes metrics using the
+// templates and tags configured on p.
+func NewPickleParser(p *Parser) *PickleParser {
+	return &PickleParser{parser: p, MaxPayloadBytes: DefaultMaxPickleLength}
+}
+
+// Parse decodes a single pickle batch -- a 4-byte big-endian length
+// prefix followed by that many bytes of pickled data -- and returns the
+// decoded points. Any trailing bytes in buf beyond the framed batch are
+// ignored; callers reading from a stream should only pass buf up
+// through the end of one frame.
+func (pp *PickleParser) Parse(buf []byte) ([]models.Point, error) {
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("graphite pickle: buffer too short for length prefix")
+	}
+
+	length := binary.BigEndian.Uint32(buf[:4])
+	max := pp.MaxPayloadBytes
+	if max <= 0 {
+		max = DefaultMaxPickleLength
+	}
+	if int(length) > max {
+		return nil, fmt.Errorf("graphite pickle: payload of %d bytes exceeds maximum of %d bytes", length, max)
+	}
+	if len(buf) < 4+int(length) {
+		return nil, fmt.Errorf("graphite pickle: buffer shorter than declared payload length %d", length)
+	}
+
+	metrics, err := decodePickle(buf[4 : 4+int(length)])
+	if err != nil {
+		return nil, err
+	}
+	return pp.points(metrics)
+}
+
+// points converts decoded (metric, timestamp, value) tuples into
+// models.Points, running each metric name through the same matcher and
+// template application used by Parser.Parse.
+func (pp *PickleParser) points(metrics []pickleMetric) ([]models.Point, error) {
+	points := make([]models.Point, 0, len(metrics))
+	for _, m := range metrics {
+		name, tagString := splitMetricTags(m.name, pp.parser.enableTagSupport)
+
+		template := pp.parser.matcher.Match(name)
+		measurement, tags, field, err := template.Apply(name)
+		if err != nil {
+			return nil, err
+		}
+		if measurement == "" {
+			measurement = name
+		}
+
+		if tagString != "" {
+			metricTags, err := parseMetricTags(tagString)
+			if err != nil {
+				return nil, fmt.Errorf("graphite pickle: metric %q tags: %s", m.name, err)
+			}
+			for k, v := range metricTags {
+				tags[k] = v
+			}
+		}
+
+		if math.IsNaN(m.value) || math.IsInf(m.value, 0) {
+			return nil, &UnsupportedValueError{Field: m.name, Value: m.value}
+		}
+
+		fieldValues := map[string]interface{}{}
+		if field != "" {
+			fieldValues[field] = m.value
+		} else {
+			fieldValues["value"] = m.value
+		}
+
+		for _, t := range pp.parser.tags {
+			if _, ok := tags[string(t.Key)]; !ok {
+				tags[string(t.Key)] = string(t.Value)
+			}
+		}
+
+		timestamp := pickleTimestamp(m.timestamp)
+		if timestamp.Before(MinDate) || timestamp.After(MaxDate) {
+			return nil, fmt.Errorf("graphite pickle: metric %q timestamp out of range", m.name)
+		}
+
+		point, err := models.NewPoint(measurement, models.NewTags(tags), fieldValues, timestamp)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// pickleTimestamp converts a Carbon pickle timestamp, a Unix time in
+// seconds that may carry a fractional component, into a time.Time.
+func pickleTimestamp(unixTime float64) time.Time {
+	return time.Unix(int64(unixTime), int64((unixTime-math.Floor(unixTime))*float64(time.Second))).UTC()
+}
+
+// pickleMetric is a single (metric, (timestamp, value)) tuple decoded
+// from a pickle batch.
+type pickleMetric struct {
+	name      string
+	timestamp float64
+	value     float64
+}
+
+// Pickle opcodes used by carbon-relay and carbon-c-relay when emitting
+// batches. This is not a general purpose unpickler -- it implements just
+// enough of the pickle VM to decode a list of (metric, (timestamp,
+// value)) tuples.
+const (
+	opProto            = 0x80
+	opEmptyList        = ']'
+	opMark             = '('
+	opAppend           = 'a'
+	opAppends          = 'e'
+	opTuple            = 't'
+	opTuple2           = 0x86
+	opShortBinUnicode  = 0x8c
+	opBinUnicode       = 'X'
+	opShortBinString   = 'U'
+	opBinInt1          = 'K'
+	opBinInt2          = 'M'
+	opBinInt           = 'J'
+	opLong1            = 0x8a
+	opBinFloat         = 'G'
+	opNewTrue          = 0x88
+	opNewFalse         = 0x89
+	opMemoize          = 0x94
+	opBinPut           = 'q'
+	opBinGet           = 'h'
+	opStop             = '.'
+)
+
+// pickleMarker is pushed onto the VM stack by MARK and popped back off by
+// TUPLE/APPENDS to delimit the items collected since the last mark.
+type pickleMarker struct{}
+
+// pickleVM is a minimal stack machine that understands the small subset
+// of the pickle protocol that carbon emits: nested lists of tuples built
+// from MARK/APPENDS, the single-item APPEND used for one-element lists,
+// and TUPLE/TUPLE2, memoized with BINPUT/BINGET or MEMOIZE.
+type pickleVM struct {
+	buf   []byte
+	pos   int
+	stack []interface{}
+	memo  map[int]interface{}
+}
+
+func decodePickle(buf []byte) ([]pickleMetric, error) {
+	vm := &pickleVM{buf: buf, memo: map[int]interface{}{}}
+	v, err := vm.run()
+	if err != nil {
+		return nil, err
+	}
+
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("graphite pickle: expected a list at the top level, got %T", v)
+	}
+
+	metrics := make([]pickleMetric, 0, len(list))
+	for _, item := range list {
+		m, err := decodePickleMetric(item)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+func decodePickleMetric(v interface{}) (pickleMetric, error) {
+	outer, ok := v.([]interface{})
+	if !ok || len(outer) != 2 {
+		return pickleMetric{}, fmt.Errorf("graphite pickle: expected a (metric, (timestamp, value)) tuple, got %T", v)
+	}
+	name, ok := outer[0].(string)
+	if !ok {
+		return pickleMetric{}, fmt.Errorf("graphite pickle: expected metric name to be a string, got %T", outer[0])
+	}
+	inner, ok := outer[1].([]interface{})
+	if !ok || len(inner) != 2 {
+		return pickleMetric{}, fmt.Errorf("graphite pickle: expected (timestamp, value) tuple for %q, got %T", name, outer[1])
+	}
+	timestamp, err := pickleNumber(inner[0])
+	if err != nil {
+		return pickleMetric{}, fmt.Errorf("graphite pickle: metric %q timestamp: %s", name, err)
+	}
+	value, err := pickleNumber(inner[1])
+	if err != nil {
+		return pickleMetric{}, fmt.Errorf("graphite pickle: metric %q value: %s", name, err)
+	}
+	return pickleMetric{name: name, timestamp: timestamp, value: value}, nil
+}
+
+func pickleNumber(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case int64:
+		return float64(n), nil
+	default:
+		return 0, fmt.Errorf("expected a number, got %T", v)
+	}
+}
+
+func (vm *pickleVM) run() (interface{}, error) {
+	for {
+		op, err := vm.readByte()
+		if err != nil {
+			return nil, fmt.Errorf("graphite pickle: %s", err)
+		}
+
+		switch op {
+		case opProto:
+			if _, err := vm.readByte(); err != nil {
+				return nil, fmt.Errorf("graphite pickle: truncated PROTO: %s", err)
+			}
+		case opEmptyList:
+			vm.push([]interface{}{})
+		case opMark:
+			vm.push(pickleMarker{})
+		case opTuple:
+			items := vm.popToMark()
+			vm.push(items)
+		case opTuple2:
+			if len(vm.stack) < 2 {
+				return nil, fmt.Errorf("graphite pickle: TUPLE2 with too few items on the stack")
+			}
+			b := vm.pop()
+			a := vm.pop()
+			vm.push([]interface{}{a, b})
+		case opAppend:
+			// Python's pickler emits a single-item APPEND instead of
+			// MARK+APPENDS whenever the list being built has exactly
+			// one element, which happens for single-metric batches.
+			if len(vm.stack) < 2 {
+				return nil, fmt.Errorf("graphite pickle: APPEND with too few items on the stack")
+			}
+			item := vm.pop()
+			list, ok := vm.stack[len(vm.stack)-1].([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("graphite pickle: APPEND target is not a list")
+			}
+			vm.stack[len(vm.stack)-1] = append(list, item)
+		case opAppends:
+			items := vm.popToMark()
+			if len(vm.stack) == 0 {
+				return nil, fmt.Errorf("graphite pickle: APPENDS with no list on the stack")
+			}
+			list, ok := vm.stack[len(vm.stack)-1].([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("graphite pickle: APPENDS target is not a list")
+			}
+			vm.stack[len(vm.stack)-1] = append(list, items...)
+		case opShortBinUnicode:
+			s, err := vm.readLengthPrefixedString(1)
+			if err != nil {
+				return nil, err
+			}
+			vm.push(s)
+		case opBinUnicode:
+			s, err := vm.readLengthPrefixedString(4)
+			if err != nil {
+				return nil, err
+			}
+			vm.push(s)
+		case opShortBinString:
+			s, err := vm.readLengthPrefixedString(1)
+			if err != nil {
+				return nil, err
+			}
+			vm.push(s)
+		case opBinInt1:
+			b, err := vm.readByte()
+			if err != nil {
+				return nil, fmt.Errorf("graphite pickle: truncated BININT1: %s", err)
+			}
+			vm.push(int64(b))
+		case opBinInt2:
+			b, err := vm.readN(2)
+			if err != nil {
+				return nil, fmt.Errorf("graphite pickle: truncated BININT2: %s", err)
+			}
+			vm.push(int64(binary.LittleEndian.Uint16(b)))
+		case opBinInt:
+			b, err := vm.readN(4)
+			if err != nil {
+				return nil, fmt.Errorf("graphite pickle: truncated BININT: %s", err)
+			}
+			vm.push(int64(int32(binary.LittleEndian.Uint32(b))))
+		case opLong1:
+			n, err := vm.readByte()
+			if err != nil {
+				return nil, fmt.Errorf("graphite pickle: truncated LONG1: %s", err)
+			}
+			b, err := vm.readN(int(n))
+			if err != nil {
+				return nil, fmt.Errorf("graphite pickle: truncated LONG1 payload: %s", err)
+			}
+			vm.push(decodeLong1(b))
+		case opBinFloat:
+			b, err := vm.readN(8)
+			if err != nil {
+				return nil, fmt.Errorf("graphite pickle: truncated BINFLOAT: %s", err)
+			}
+			vm.push(math.Float64frombits(binary.BigEndian.Uint64(b)))
+		case opNewTrue:
+			vm.push(true)
+		case opNewFalse:
+			vm.push(false)
+		case opMemoize:
+			if len(vm.stack) == 0 {
+				return nil, fmt.Errorf("graphite pickle: MEMOIZE with empty stack")
+			}
+			vm.memo[len(vm.memo)] = vm.stack[len(vm.stack)-1]
+		case opBinPut:
+			idx, err := vm.readByte()
+			if err != nil {
+				return nil, fmt.Errorf("graphite pickle: truncated BINPUT: %s", err)
+			}
+			if len(vm.stack) == 0 {
+				return nil, fmt.Errorf("graphite pickle: BINPUT with empty stack")
+			}
+			vm.memo[int(idx)] = vm.stack[len(vm.stack)-1]
+		case opBinGet:
+			idx, err := vm.readByte()
+			if err != nil {
+				return nil, fmt.Errorf("graphite pickle: truncated BINGET: %s", err)
+			}
+			v, ok := vm.memo[int(idx)]
+			if !ok {
+				return nil, fmt.Errorf("graphite pickle: BINGET of unset memo index %d", idx)
+			}
+			vm.push(v)
+		case opStop:
+			if len(vm.stack) == 0 {
+				return nil, fmt.Errorf("graphite pickle: STOP with empty stack")
+			}
+			return vm.pop(), nil
+		default:
+			return nil, fmt.Errorf("graphite pickle: unsupported opcode 0x%02x", op)
+		}
+	}
+}
+
+func (vm *pickleVM) push(v interface{}) { vm.stack = append(vm.stack, v) }
+
+func (vm *pickleVM) pop() interface{} {
+	v := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return v
+}
+
+// popToMark pops and returns items up to and including the most recent
+// pickleMarker, in their original order, discarding the marker itself.
+func (vm *pickleVM) popToMark() []interface{} {
+	for i := len(vm.stack) - 1; i >= 0; i-- {
+		if _, ok := vm.stack[i].(pickleMarker); ok {
+			items := make([]interface{}, len(vm.stack)-i-1)
+			copy(items, vm.stack[i+1:])
+			vm.stack = vm.stack[:i]
+			return items
+		}
+	}
+	return nil
+}
+
+func (vm *pickleVM) readByte() (byte, error) {
+	if vm.pos >= len(vm.buf) {
+		return 0, fmt.Errorf("unexpected end of input")
+	}
+	b := vm.buf[vm.pos]
+	vm.pos++
+	return b, nil
+}
+
+func (vm *pickleVM) readN(n int) ([]byte, error) {
+	if vm.pos+n > len(vm.buf) {
+		return nil, fmt.Errorf("unexpected end of input")
+	}
+	b := vm.buf[vm.pos : vm.pos+n]
+	vm.pos += n
+	return b, nil
+}
+
+func (vm *pickleVM) readLengthPrefixedString(lenBytes int) (string, error) {
+	lb, err := vm.readN(lenBytes)
+	if err != nil {
+		return "", fmt.Errorf("graphite pickle: truncated string length: %s", err)
+	}
+
+	var length int
+	switch lenBytes {
+	case 1:
+		length = int(lb[0])
+	case 4:
+		length = int(binary.LittleEndian.Uint32(lb))
+	default:
+		return "", fmt.Errorf("graphite pickle: unsupported string length prefix of %d bytes", lenBytes)
+	}
+
+	b, err := vm.readN(length)
+	if err != nil {
+		return "", fmt.Errorf("graphite pickle: truncated string payload: %s", err)
+	}
+	return string(b), nil
+}
+
+// decodeLong1 decodes the little-endian, two's-complement integer
+// payload of a LONG1 opcode.
+func decodeLong1(b []byte) int64 {
+	if len(b) == 0 {
+		return 0
+	}
+	var v int64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | int64(b[i])
+	}
+	// Sign-extend if the high bit of the most significant byte is set.
+	if b[len(b)-1]&0x80 != 0 && len(b) < 8 {
+		v -= 1 << (8 * uint(len(b)))
+	}
+	return v
+}
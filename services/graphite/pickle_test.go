@@ -0,0 +1,91 @@
+package graphite
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// frame prepends payload with the 4-byte big-endian length prefix
+// PickleParser.Parse expects.
+func frame(payload []byte) []byte {
+	buf := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)))
+	copy(buf[4:], payload)
+	return buf
+}
+
+// pickleOneMetric is the protocol-2 encoding, as produced by CPython's
+// pickle.dumps, of [("a.b.c", (1500000000, 42.0))] -- a single-metric
+// batch, which the pickler emits using the single-item APPEND opcode
+// rather than MARK+APPENDS.
+var pickleOneMetric = []byte{
+	0x80, 0x02, 0x5d, 0x71, 0x00, 0x58, 0x05, 0x00, 0x00, 0x00, 0x61, 0x2e, 0x62, 0x2e, 0x63, 0x71,
+	0x01, 0x4a, 0x00, 0x2f, 0x68, 0x59, 0x47, 0x40, 0x45, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x86,
+	0x71, 0x02, 0x86, 0x71, 0x03, 0x61, 0x2e,
+}
+
+// pickleTwoMetrics is the protocol-2 encoding of
+// [("a.b.c", (1500000000, 42.0)), ("d.e.f", (1500000001, 43.5))], a
+// batch that uses the usual MARK+APPENDS opcodes.
+var pickleTwoMetrics = []byte{
+	0x80, 0x02, 0x5d, 0x71, 0x00, 0x28, 0x58, 0x05, 0x00, 0x00, 0x00, 0x61, 0x2e, 0x62, 0x2e, 0x63,
+	0x71, 0x01, 0x4a, 0x00, 0x2f, 0x68, 0x59, 0x47, 0x40, 0x45, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x86, 0x71, 0x02, 0x86, 0x71, 0x03, 0x58, 0x05, 0x00, 0x00, 0x00, 0x64, 0x2e, 0x65, 0x2e, 0x66,
+	0x71, 0x04, 0x4a, 0x01, 0x2f, 0x68, 0x59, 0x47, 0x40, 0x45, 0xc0, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x86, 0x71, 0x05, 0x86, 0x71, 0x06, 0x65, 0x2e,
+}
+
+func TestPickleParser_SingleMetricBatchUsesAppend(t *testing.T) {
+	p, err := NewParser(nil, nil)
+	if err != nil {
+		t.Fatalf("NewParser failed: %s", err)
+	}
+	pp := NewPickleParser(p)
+
+	points, err := pp.Parse(frame(pickleOneMetric))
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	if len(points) != 1 {
+		t.Fatalf("got %d points, want 1", len(points))
+	}
+	if !strings.HasPrefix(points[0].String(), "a_b_c ") {
+		t.Errorf("points[0] = %q, want measurement %q", points[0].String(), "a_b_c")
+	}
+}
+
+func TestPickleParser_MultiMetricBatchUsesAppends(t *testing.T) {
+	p, err := NewParser(nil, nil)
+	if err != nil {
+		t.Fatalf("NewParser failed: %s", err)
+	}
+	pp := NewPickleParser(p)
+
+	points, err := pp.Parse(frame(pickleTwoMetrics))
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("got %d points, want 2", len(points))
+	}
+	if !strings.HasPrefix(points[0].String(), "a_b_c ") {
+		t.Errorf("points[0] = %q, want measurement %q", points[0].String(), "a_b_c")
+	}
+	if !strings.HasPrefix(points[1].String(), "d_e_f ") {
+		t.Errorf("points[1] = %q, want measurement %q", points[1].String(), "d_e_f")
+	}
+}
+
+func TestPickleParser_RejectsOversizedPayload(t *testing.T) {
+	p, err := NewParser(nil, nil)
+	if err != nil {
+		t.Fatalf("NewParser failed: %s", err)
+	}
+	pp := NewPickleParser(p)
+	pp.MaxPayloadBytes = 4
+
+	if _, err := pp.Parse(frame(pickleOneMetric)); err == nil {
+		t.Fatalf("expected an error for a payload larger than MaxPayloadBytes")
+	}
+}
@@ -0,0 +1,76 @@
+package graphite
+
+import "testing"
+
+func TestNewParser_EnablesTagSupportByDefault(t *testing.T) {
+	p, err := NewParser(nil, nil)
+	if err != nil {
+		t.Fatalf("NewParser failed: %s", err)
+	}
+
+	point, err := p.Parse("disk.used;host=web01;mountpoint=/var 42 1500000000")
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	s := point.String()
+	// The tags must have been split off, so the measurement ends right
+	// before the "," that introduces them.
+	if got, want := s[:len("disk_used,")], "disk_used,"; got != want {
+		t.Errorf("measurement = %q, want %q", s, want)
+	}
+}
+
+func TestParserWithOptions_CanDisableTagSupport(t *testing.T) {
+	p, err := NewParserWithOptions(Options{Separator: DefaultSeparator, EnableTagSupport: false})
+	if err != nil {
+		t.Fatalf("NewParserWithOptions failed: %s", err)
+	}
+
+	point, err := p.Parse("disk.used;host=web01 42 1500000000")
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	// With tag support disabled, the ";host=web01" is just part of the
+	// (unmatched) dotted path and folds into the measurement name.
+	if got, want := point.String()[:len("disk_used;host=web01")], "disk_used;host=web01"; got != want {
+		t.Errorf("measurement = %q, want %q", point.String(), want)
+	}
+}
+
+func TestSplitMetricTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		metric  string
+		tags    string
+	}{
+		{name: "disabled leaves name untouched", enabled: false, metric: "disk.used;host=web01", tags: ""},
+		{name: "enabled splits on first semicolon", enabled: true, metric: "disk.used", tags: "host=web01;mountpoint=/var"},
+		{name: "enabled with no semicolon", enabled: true, metric: "disk.used", tags: ""},
+	}
+
+	cases := []string{"disk.used;host=web01", "disk.used;host=web01;mountpoint=/var", "disk.used"}
+	for i, tt := range tests {
+		metric, tags := splitMetricTags(cases[i], tt.enabled)
+		if metric != tt.metric || tags != tt.tags {
+			t.Errorf("%s: splitMetricTags(%q, %v) = (%q, %q), want (%q, %q)",
+				tt.name, cases[i], tt.enabled, metric, tags, tt.metric, tt.tags)
+		}
+	}
+}
+
+func TestParseMetricTags(t *testing.T) {
+	tags, err := parseMetricTags("host=web01;mountpoint=/var")
+	if err != nil {
+		t.Fatalf("parseMetricTags failed: %s", err)
+	}
+	if tags["host"] != "web01" || tags["mountpoint"] != "/var" {
+		t.Errorf("tags = %v, want host=web01, mountpoint=/var", tags)
+	}
+
+	for _, s := range []string{"host", "host=", "=web01", "host=a;b=c=d"} {
+		if _, err := parseMetricTags(s); err == nil {
+			t.Errorf("parseMetricTags(%q) expected an error", s)
+		}
+	}
+}